@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/aryann/difflib"
+	"gopkg.in/yaml.v3"
+
+	"github.com/nickpetrovic/helmfile-next-version/helmexec"
+)
+
+// allowedLevel is the highest semver jump a bump is permitted to make.
+type allowedLevel int
+
+const (
+	allowPatch allowedLevel = iota
+	allowMinor
+	allowMajor
+)
+
+func parseAllowedLevel(s string) (allowedLevel, error) {
+	switch s {
+	case "patch":
+		return allowPatch, nil
+	case "minor":
+		return allowMinor, nil
+	case "major":
+		return allowMajor, nil
+	default:
+		return 0, fmt.Errorf("invalid --allow value %q, must be one of [major|minor|patch]", s)
+	}
+}
+
+// bumpLevel classifies how big a jump from current to latest is.
+func bumpLevel(current, latest *semver.Version) allowedLevel {
+	if current.Major() != latest.Major() {
+		return allowMajor
+	}
+	if current.Minor() != latest.Minor() {
+		return allowMinor
+	}
+	return allowPatch
+}
+
+func (l allowedLevel) String() string {
+	switch l {
+	case allowMajor:
+		return "major"
+	case allowMinor:
+		return "minor"
+	default:
+		return "patch"
+	}
+}
+
+// stringSliceFlag collects repeated occurrences of a flag, e.g. `--only=a --only=b`.
+type stringSliceFlag []string
+
+func (f *stringSliceFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringSliceFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+type bumpChange struct {
+	Release string
+	Chart   string
+	Old     string
+	New     string
+	Level   allowedLevel
+}
+
+func runBump(args []string) error {
+	fs := flag.NewFlagSet("bump", flag.ExitOnError)
+
+	flagPath := fs.String("path", "helmfile.yaml", "Path to helmfile.yaml.")
+	flagEnvironment := fs.String("environment", "default", "Name of the helmfile environment whose values to render.")
+	flagAllow := fs.String("allow", "minor", "Highest allowed semver jump. Valid values [major|minor|patch].")
+	flagDryRun := fs.Bool("dry-run", false, "Print a diff instead of writing changes.")
+	flagPRBody := fs.Bool("pr-body", false, "Print a Markdown changelog of the bumps instead of a diff.")
+	var flagOnly stringSliceFlag
+	fs.Var(&flagOnly, "only", "Only bump the named release. Repeatable.")
+	var flagExact stringSliceFlag
+	fs.Var(&flagExact, "exact", "Pin a chart to an exact version, e.g. --exact=nginx=1.2.3. Repeatable.")
+
+	fs.Parse(args)
+
+	allow, err := parseAllowedLevel(*flagAllow)
+	if err != nil {
+		return err
+	}
+
+	only := make(map[string]bool, len(flagOnly))
+	for _, name := range flagOnly {
+		only[name] = true
+	}
+
+	exact := make(map[string]string, len(flagExact))
+	for _, pin := range flagExact {
+		chart, version, ok := strings.Cut(pin, "=")
+		if !ok {
+			return fmt.Errorf("invalid --exact value %q, expected <chart>=<version>", pin)
+		}
+		exact[chart] = version
+	}
+
+	original, err := os.ReadFile(*flagPath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", *flagPath, err)
+	}
+
+	helmfile, err := NewHelmfile(*flagPath, *flagEnvironment)
+	if err != nil {
+		return fmt.Errorf("failed to load helmfile: %w", err)
+	}
+
+	he, err := helmexec.New()
+	if err != nil {
+		return fmt.Errorf("failed to detect helm version: %w", err)
+	}
+
+	updateManager := NewUpdateManager(helmfile)
+	updateManager.HelmExec = he
+	if err := updateManager.CheckForUpdates(); err != nil {
+		return fmt.Errorf("failed to check for updates: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(original, &doc); err != nil {
+		return fmt.Errorf("failed to unmarshal YAML data: %w", err)
+	}
+
+	releaseNodes, err := findReleaseNodes(&doc)
+	if err != nil {
+		return err
+	}
+
+	var changes []bumpChange
+	for _, comparer := range updateManager.Comparisons {
+		if len(only) > 0 && !only[comparer.Name()] {
+			continue
+		}
+
+		// For a constrained release, Current.Version is a range (e.g.
+		// "^1.2.3") rather than a concrete version, so classify the jump
+		// using the two concrete versions we already resolved instead.
+		fromVersion := comparer.Current.Version
+		targetVersion := comparer.LatestAvailable.Version
+		if comparer.Constrained {
+			fromVersion = comparer.Latest.Version
+		}
+
+		pinned, isExact := exact[comparer.Current.Chart]
+		if isExact {
+			targetVersion = pinned
+		} else if !comparer.HasUpdate() {
+			continue
+		}
+
+		currentSemver, err := semver.NewVersion(strings.TrimPrefix(fromVersion, "v"))
+		if err != nil {
+			continue
+		}
+		latestSemver, err := semver.NewVersion(strings.TrimPrefix(targetVersion, "v"))
+		if err != nil {
+			continue
+		}
+
+		// --exact is an explicit pin and overrides the --allow class filter;
+		// it only applies to the default, discovered targetVersion.
+		level := bumpLevel(currentSemver, latestSemver)
+		if !isExact && level > allow {
+			continue
+		}
+
+		versionNode, ok := releaseNodes[comparer.Name()]
+		if !ok {
+			continue
+		}
+		versionNode.Value = targetVersion
+
+		changes = append(changes, bumpChange{
+			Release: comparer.Name(),
+			Chart:   comparer.Current.Chart,
+			Old:     comparer.Current.Version,
+			New:     targetVersion,
+			Level:   level,
+		})
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No bumps to apply.")
+		return nil
+	}
+
+	bumped, err := marshalYAML(&doc, detectIndent(original))
+	if err != nil {
+		return fmt.Errorf("failed to marshal YAML data: %w", err)
+	}
+
+	if *flagPRBody {
+		fmt.Print(renderPRBody(changes))
+		return nil
+	}
+
+	if *flagDryRun {
+		fmt.Println(renderDiff(string(original), string(bumped), *flagPath))
+		return nil
+	}
+
+	if err := os.WriteFile(*flagPath, bumped, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", *flagPath, err)
+	}
+
+	for _, change := range changes {
+		fmt.Printf("%s: %s -> %s (%s)\n", change.Release, change.Old, change.New, change.Level)
+	}
+
+	return nil
+}
+
+// findReleaseNodes walks the document's releases list and returns the
+// `version:` scalar node for each release, keyed by release name, so it can
+// be rewritten in place without disturbing comments or formatting elsewhere
+// in the document.
+func findReleaseNodes(doc *yaml.Node) (map[string]*yaml.Node, error) {
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("empty YAML document")
+	}
+
+	root := doc.Content[0]
+
+	var releases *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "releases" {
+			releases = root.Content[i+1]
+			break
+		}
+	}
+	if releases == nil {
+		return nil, fmt.Errorf("no releases found in document")
+	}
+
+	nodes := make(map[string]*yaml.Node)
+	for _, release := range releases.Content {
+		var name, version *yaml.Node
+		for i := 0; i+1 < len(release.Content); i += 2 {
+			switch release.Content[i].Value {
+			case "name":
+				name = release.Content[i+1]
+			case "version":
+				version = release.Content[i+1]
+			}
+		}
+		if name != nil && version != nil {
+			nodes[name.Value] = version
+		}
+	}
+
+	return nodes, nil
+}
+
+// detectIndent returns the number of spaces the source document uses to
+// indent sequence items (e.g. "  - name: ..."), defaulting to 2 when none is
+// found, so marshalYAML can reproduce it instead of yaml.v3's default of 4.
+func detectIndent(content []byte) int {
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if !strings.HasPrefix(trimmed, "- ") && trimmed != "-" {
+			continue
+		}
+		if indent := len(line) - len(trimmed); indent > 0 {
+			return indent
+		}
+	}
+	return 2
+}
+
+// marshalYAML encodes doc using indent spaces of indentation, so a bump
+// doesn't reformat the rest of the document along with the fields it
+// actually changed.
+func marshalYAML(doc *yaml.Node, indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(indent)
+
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func renderDiff(original, bumped, path string) string {
+	diff := difflib.Diff(strings.Split(original, "\n"), strings.Split(bumped, "\n"))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", path, path)
+	for _, d := range diff {
+		fmt.Fprintln(&b, d.String())
+	}
+
+	return b.String()
+}
+
+func renderPRBody(changes []bumpChange) string {
+	var b strings.Builder
+	b.WriteString("## Chart version bumps\n\n")
+	b.WriteString("| Release | Chart | Old | New | Level |\n")
+	b.WriteString("|---|---|---|---|---|\n")
+	for _, change := range changes {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", change.Release, change.Chart, change.Old, change.New, change.Level)
+	}
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// parseVersionConstraint reports whether version is a semver range (e.g.
+// "^1.2.3", "~1.2") rather than a plain, pinned version. A plain version is
+// itself a valid constraint, so exact versions are excluded here and left to
+// the existing single-version lookup.
+func parseVersionConstraint(version string) (*semver.Constraints, bool) {
+	if _, err := semver.NewVersion(strings.TrimPrefix(version, "v")); err == nil {
+		return nil, false
+	}
+
+	constraint, err := semver.NewConstraint(version)
+	if err != nil {
+		return nil, false
+	}
+
+	return constraint, true
+}
+
+// getConstrainedReleaseComparer resolves both the latest version permitted
+// by a release's semver constraint and the latest version available in the
+// repo overall, so callers can tell a safe bump from a blocked one.
+func (um *UpdateManager) getConstrainedReleaseComparer(release Release, constraint *semver.Constraints) (*ReleaseComparer, error) {
+	args, err := um.searchRepoArgs(release.Chart, true)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("helm", args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for chart %s: %w", release.Chart, err)
+	}
+
+	var chart []HelmChartInfo
+	if err := yaml.Unmarshal(output, &chart); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML data: %w", err)
+	}
+
+	if len(chart) == 0 {
+		return nil, fmt.Errorf("chart %s not found", release.Chart)
+	}
+
+	var available, allowed semver.Collection
+	for _, c := range chart {
+		v, err := semver.NewVersion(strings.TrimPrefix(c.Version, "v"))
+		if err != nil {
+			continue
+		}
+		available = append(available, v)
+		if constraint.Check(v) {
+			allowed = append(allowed, v)
+		}
+	}
+
+	if len(available) == 0 {
+		return nil, fmt.Errorf("no valid semver versions found for chart %s", release.Chart)
+	}
+	sort.Sort(available)
+	latestAvailable := available[len(available)-1]
+
+	latestAllowedVersion := ""
+	blocked := len(allowed) == 0
+	if !blocked {
+		sort.Sort(allowed)
+		latestAllowedVersion = allowed[len(allowed)-1].Original()
+	}
+
+	return &ReleaseComparer{
+		Current:     release,
+		Constrained: true,
+		Blocked:     blocked,
+		Latest: Release{
+			Name:    release.Name,
+			Chart:   chart[0].Name,
+			Version: latestAllowedVersion,
+		},
+		LatestAvailable: Release{
+			Name:    release.Name,
+			Chart:   chart[0].Name,
+			Version: latestAvailable.Original(),
+		},
+	}, nil
+}
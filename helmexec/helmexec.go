@@ -0,0 +1,69 @@
+// Package helmexec detects the installed helm binary's version so callers
+// can compose argv based on what that version actually supports, rather than
+// hard-coding flags that may not exist yet (or that regressed) across helm
+// releases.
+package helmexec
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// MinimumVersion is the oldest helm release this tool is tested against.
+var MinimumVersion = semver.MustParse("3.0.0")
+
+// HelmExec wraps the detected helm version and exposes capability checks for
+// flags that only exist on newer helm releases.
+type HelmExec struct {
+	version *semver.Version
+}
+
+// New shells out to `helm version` and returns a HelmExec for the detected
+// version, or an error if helm isn't found or is older than MinimumVersion.
+func New() (*HelmExec, error) {
+	cmd := exec.Command("helm", "version", "--template", "{{.Version}}")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect helm version: %w", err)
+	}
+
+	version, err := semver.NewVersion(strings.TrimSpace(string(output)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse helm version %q: %w", strings.TrimSpace(string(output)), err)
+	}
+
+	if version.LessThan(MinimumVersion) {
+		return nil, fmt.Errorf("helm %s is below the minimum supported version %s", version, MinimumVersion)
+	}
+
+	return &HelmExec{version: version}, nil
+}
+
+// Version returns the detected helm version.
+func (h *HelmExec) Version() *semver.Version {
+	return h.version
+}
+
+// SupportsFailOnUpdateFail reports whether `helm repo update` accepts
+// --fail-on-repo-update-fail, added in helm 3.6.0.
+func (h *HelmExec) SupportsFailOnUpdateFail() bool {
+	return mustConstraint(">= 3.6.0").Check(h.version)
+}
+
+// HasForceUpdateRegression reports whether this helm version falls in the
+// 3.3.2-3.3.4 window where --force-update silently failed to update
+// existing releases.
+func (h *HelmExec) HasForceUpdateRegression() bool {
+	return mustConstraint(">= 3.3.2, <= 3.3.4").Check(h.version)
+}
+
+func mustConstraint(c string) *semver.Constraints {
+	constraint, err := semver.NewConstraint(c)
+	if err != nil {
+		panic(err)
+	}
+	return constraint
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/imdario/mergo"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvironmentSpec mirrors the subset of roboll/helmfile's environment spec we
+// need to render `{{ .Environment.Values.foo }}` expressions.
+type EnvironmentSpec struct {
+	Values map[string]interface{} `yaml:"values"`
+}
+
+// SubHelmfileSpec references another helmfile listed under `helmfiles:`, whose
+// releases are loaded and appended. Bases are plain paths, not this type.
+type SubHelmfileSpec struct {
+	Path        string `yaml:"path"`
+	Environment string `yaml:"environment"`
+}
+
+// SetValue is a single `set:` override on a release, e.g. `--set name=value`.
+type SetValue struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+type Helmfile struct {
+	Environments map[string]EnvironmentSpec `yaml:"environments"`
+	Bases        []string                   `yaml:"bases"`
+	Helmfiles    []SubHelmfileSpec          `yaml:"helmfiles"`
+	Releases     []Release                  `yaml:"releases"`
+}
+
+type templateContext struct {
+	Environment struct {
+		Name   string
+		Values map[string]interface{}
+	}
+	Values map[string]interface{}
+}
+
+func newTemplateContext(environment string, values map[string]interface{}) templateContext {
+	ctx := templateContext{Values: values}
+	ctx.Environment.Name = environment
+	ctx.Environment.Values = values
+	return ctx
+}
+
+// NewHelmfile loads the helmfile at path for the given environment, rendering
+// Go-template expressions and recursively merging any `bases` and nested
+// `helmfiles` it references.
+func NewHelmfile(path string, environment string) (*Helmfile, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("file %s does not exist", path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	// Environments are declared statically, and may live in a base rather
+	// than the file itself, so resolve them across the whole base chain
+	// before templating the rest of the document.
+	envValues, err := collectEnvironmentValues(path, environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect environment values for %s: %w", path, err)
+	}
+
+	rendered, err := renderTemplate(content, newTemplateContext(environment, envValues))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render templates in %s: %w", path, err)
+	}
+
+	var h Helmfile
+	if err := yaml.Unmarshal(rendered, &h); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML data: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+
+	for _, basePath := range h.Bases {
+		base, err := NewHelmfile(filepath.Join(dir, basePath), environment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load base %s: %w", basePath, err)
+		}
+
+		releases := mergeReleasesByName(base.Releases, h.Releases)
+
+		if err := mergo.Merge(base, h, mergo.WithOverride, mergo.WithAppendSlice); err != nil {
+			return nil, fmt.Errorf("failed to merge base %s: %w", basePath, err)
+		}
+		base.Releases = releases
+
+		h = *base
+	}
+
+	for _, sub := range h.Helmfiles {
+		subEnvironment := environment
+		if sub.Environment != "" {
+			subEnvironment = sub.Environment
+		}
+
+		subHelmfile, err := NewHelmfile(filepath.Join(dir, sub.Path), subEnvironment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load sub-helmfile %s: %w", sub.Path, err)
+		}
+
+		h.Releases = append(h.Releases, subHelmfile.Releases...)
+	}
+
+	return &h, nil
+}
+
+// mergeReleasesByName overlays overlay on top of base, matching releases by
+// Name: a release declared in both is replaced entirely by the overlay's
+// entry (base's ordering is preserved), and overlay-only releases are
+// appended. This gives bases+overlays the "redeclare to override" semantics
+// the request describes, instead of duplicating same-named releases.
+func mergeReleasesByName(base, overlay []Release) []Release {
+	merged := make([]Release, len(base))
+	copy(merged, base)
+
+	index := make(map[string]int, len(merged))
+	for i, release := range merged {
+		index[release.Name] = i
+	}
+
+	for _, release := range overlay {
+		if i, ok := index[release.Name]; ok {
+			merged[i] = release
+			continue
+		}
+		index[release.Name] = len(merged)
+		merged = append(merged, release)
+	}
+
+	return merged
+}
+
+// collectEnvironmentValues resolves the values for environment across path's
+// entire base chain, untemplated, so a child helmfile can template its own
+// content against environment values declared in a base it hasn't merged in
+// yet. Bases are applied first, in order, with the file's own values (and
+// later bases) taking precedence.
+func collectEnvironmentValues(path string, environment string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	var holder struct {
+		Environments map[string]EnvironmentSpec `yaml:"environments"`
+		Bases        []string                   `yaml:"bases"`
+	}
+	if err := yaml.Unmarshal(content, &holder); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal environments from %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	values := map[string]interface{}{}
+
+	for _, basePath := range holder.Bases {
+		baseValues, err := collectEnvironmentValues(filepath.Join(dir, basePath), environment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect environment values from base %s: %w", basePath, err)
+		}
+		if err := mergo.Merge(&values, baseValues, mergo.WithOverride); err != nil {
+			return nil, fmt.Errorf("failed to merge environment values from base %s: %w", basePath, err)
+		}
+	}
+
+	if err := mergo.Merge(&values, holder.Environments[environment].Values, mergo.WithOverride); err != nil {
+		return nil, fmt.Errorf("failed to merge environment values for %s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+func renderTemplate(content []byte, ctx templateContext) ([]byte, error) {
+	tmpl, err := template.New("helmfile").Option("missingkey=zero").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ctx); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
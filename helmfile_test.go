@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeReleasesByName(t *testing.T) {
+	base := []Release{
+		{Name: "test", Chart: "repo/test", Version: "1.0.0"},
+		{Name: "other", Chart: "repo/other", Version: "1.0.0"},
+	}
+	overlay := []Release{
+		{Name: "test", Chart: "repo/test", Version: "2.0.0"},
+		{Name: "new", Chart: "repo/new", Version: "1.0.0"},
+	}
+
+	want := []Release{
+		{Name: "test", Chart: "repo/test", Version: "2.0.0"},
+		{Name: "other", Chart: "repo/other", Version: "1.0.0"},
+		{Name: "new", Chart: "repo/new", Version: "1.0.0"},
+	}
+
+	got := mergeReleasesByName(base, overlay)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeReleasesByName() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMergeReleasesByNameEmptyOverlay(t *testing.T) {
+	base := []Release{{Name: "test", Chart: "repo/test", Version: "1.0.0"}}
+
+	got := mergeReleasesByName(base, nil)
+	if !reflect.DeepEqual(got, base) {
+		t.Errorf("mergeReleasesByName() = %+v, want %+v", got, base)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
 	"sync"
 
@@ -14,6 +15,8 @@ import (
 
 	"github.com/Masterminds/semver/v3"
 	"gopkg.in/yaml.v3"
+
+	"github.com/nickpetrovic/helmfile-next-version/helmexec"
 )
 
 type HelmChartInfo struct {
@@ -23,38 +26,30 @@ type HelmChartInfo struct {
 }
 
 type Release struct {
-	Name      string `yaml:"name"`
-	Chart     string `yaml:"chart"`
-	Version   string `yaml:"version"`
-	Installed *bool  `yaml:"installed"`
-}
-
-type Helmfile struct {
-	Releases []Release `yaml:"releases"`
-}
-
-func NewHelmfile(path string) (*Helmfile, error) {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return nil, fmt.Errorf("file %s does not exist", path)
-	}
-
-	content, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
-	}
-
-	var h Helmfile
-	err = yaml.Unmarshal(content, &h)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal YAML data: %w", err)
-	}
-
-	return &h, nil
+	Name      string     `yaml:"name"`
+	Chart     string     `yaml:"chart"`
+	Version   string     `yaml:"version"`
+	Installed *bool      `yaml:"installed"`
+	OCI       bool       `yaml:"oci"`
+	Values    []string   `yaml:"values"`
+	Set       []SetValue `yaml:"set"`
 }
 
+// ReleaseComparer compares a release's current version against the versions
+// available in its repo. Latest is the highest version permitted by
+// Current.Version when it's a semver constraint (e.g. "^1.2.3"); for a plain
+// version it's simply the highest version found. LatestAvailable is always
+// the highest version found, ignoring any constraint, so a team pinned to a
+// narrow range can still see what's out there.
 type ReleaseComparer struct {
-	Current Release
-	Latest  Release
+	Current         Release
+	Latest          Release
+	LatestAvailable Release
+	Constrained     bool
+	// Blocked is true for a Constrained release when no available version
+	// satisfies its constraint, so Latest is empty. It's surfaced distinctly
+	// from "already latest" rather than silently reading as up-to-date.
+	Blocked bool
 }
 
 func (rc *ReleaseComparer) Name() string {
@@ -62,6 +57,14 @@ func (rc *ReleaseComparer) Name() string {
 }
 
 func (rc *ReleaseComparer) HasUpdate() bool {
+	if rc.Blocked {
+		return true
+	}
+
+	if rc.Constrained {
+		return rc.UpdateSeverity() != ""
+	}
+
 	currentVersion := strings.TrimPrefix(rc.Current.Version, "v")
 	latestVersion := strings.TrimPrefix(rc.Latest.Version, "v")
 
@@ -84,9 +87,51 @@ func (rc *ReleaseComparer) HasUpdate() bool {
 	return currentSemver.LessThan(latestSemver)
 }
 
+// UpdateSeverity classifies the gap between the two versions relevant to
+// this release as "major", "minor", "patch", or "blocked" by comparing
+// Major/Minor fields, returning "" when there's no meaningful gap. For a
+// constrained release the gap is between the latest allowed and latest
+// available versions; otherwise it's between the current and latest
+// versions. A Blocked release has no allowed version to compare against, so
+// it's reported as "blocked" rather than silently reading as "" (up to date).
+func (rc *ReleaseComparer) UpdateSeverity() string {
+	if rc.Blocked {
+		return "blocked"
+	}
+
+	from, to := rc.Current.Version, rc.Latest.Version
+	if rc.Constrained {
+		from, to = rc.Latest.Version, rc.LatestAvailable.Version
+	}
+
+	fromSemver, err := semver.NewVersion(strings.TrimPrefix(from, "v"))
+	if err != nil {
+		return ""
+	}
+
+	toSemver, err := semver.NewVersion(strings.TrimPrefix(to, "v"))
+	if err != nil {
+		return ""
+	}
+
+	if fromSemver.Equal(toSemver) {
+		return ""
+	}
+	if fromSemver.Major() != toSemver.Major() {
+		return "major"
+	}
+	if fromSemver.Minor() != toSemver.Minor() {
+		return "minor"
+	}
+	return "patch"
+}
+
 type UpdateManager struct {
-	Helmfile    *Helmfile
-	Comparisons []*ReleaseComparer
+	Helmfile          *Helmfile
+	Comparisons       []*ReleaseComparer
+	IncludePrerelease bool
+	Parallelism       int
+	HelmExec          *helmexec.HelmExec
 }
 
 func NewUpdateManager(helmfile *Helmfile) *UpdateManager {
@@ -105,7 +150,12 @@ func (um *UpdateManager) HasUpdates() bool {
 }
 
 func (um *UpdateManager) UpdateRepositories() error {
-	cmd := exec.Command("helm", "repo", "update")
+	args := []string{"repo", "update"}
+	if um.HelmExec != nil && um.HelmExec.SupportsFailOnUpdateFail() {
+		args = append(args, "--fail-on-repo-update-fail")
+	}
+
+	cmd := exec.Command("helm", args...)
 
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -128,6 +178,28 @@ func (um *UpdateManager) UpdateRepositories() error {
 	return nil
 }
 
+// searchRepoArgs composes the `helm search repo` argv for chart. It requires
+// um.HelmExec and logs a warning when the detected helm falls in the
+// --force-update regression window; the argv itself is the same for every
+// helm version.
+func (um *UpdateManager) searchRepoArgs(chart string, versions bool) ([]string, error) {
+	if um.HelmExec == nil {
+		return nil, fmt.Errorf("helm capabilities not detected for chart %s; set UpdateManager.HelmExec before searching", chart)
+	}
+
+	if um.HelmExec.HasForceUpdateRegression() {
+		log.Printf("warning: helm %s falls in the 3.3.2-3.3.4 --force-update regression window; the local repo index for %s may be stale\n", um.HelmExec.Version(), chart)
+	}
+
+	args := []string{"search", "repo", chart}
+	if versions {
+		args = append(args, "--versions")
+	}
+	args = append(args, "--output", "yaml")
+
+	return args, nil
+}
+
 func (um *UpdateManager) GetReleaseComparer(release Release) (*ReleaseComparer, error) {
 	installed := true
 	if release.Installed == nil {
@@ -136,12 +208,26 @@ func (um *UpdateManager) GetReleaseComparer(release Release) (*ReleaseComparer,
 
 	if strings.HasPrefix(release.Chart, "/") || strings.HasPrefix(release.Chart, "./") || strings.HasPrefix(release.Chart, "../") {
 		return &ReleaseComparer{
-			Current: release,
-			Latest:  release,
+			Current:         release,
+			Latest:          release,
+			LatestAvailable: release,
 		}, nil
 	}
 
-	cmd := exec.Command("helm", "search", "repo", release.Chart, "--output", "yaml")
+	if isOCIChart(release) {
+		return um.getOCIReleaseComparer(release)
+	}
+
+	if constraint, ok := parseVersionConstraint(release.Version); ok {
+		return um.getConstrainedReleaseComparer(release, constraint)
+	}
+
+	args, err := um.searchRepoArgs(release.Chart, false)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("helm", args...)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to search for chart %s: %w", release.Chart, err)
@@ -156,42 +242,81 @@ func (um *UpdateManager) GetReleaseComparer(release Release) (*ReleaseComparer,
 		return nil, fmt.Errorf("chart %s not found", release.Chart)
 	}
 
+	latest := Release{
+		Name:      release.Name,
+		Chart:     chart[0].Name,
+		Version:   chart[0].Version,
+		Installed: chart[0].Installed,
+	}
+
 	return &ReleaseComparer{
-		Current: release,
-		Latest: Release{
-			Name:      release.Name,
-			Chart:     chart[0].Name,
-			Version:   chart[0].Version,
-			Installed: chart[0].Installed,
-		},
+		Current:         release,
+		Latest:          latest,
+		LatestAvailable: latest,
 	}, nil
 }
 
+// chartKey groups releases that resolve to the same lookup, so the same
+// chart at the same version constraint is only ever looked up once.
+type chartKey struct {
+	Chart   string
+	Version string
+}
+
 func (um *UpdateManager) CheckForUpdates() error {
-	var err error
+	groups := make(map[chartKey][]int)
+	keys := make([]chartKey, 0, len(um.Helmfile.Releases))
+	for i, release := range um.Helmfile.Releases {
+		key := chartKey{Chart: release.Chart, Version: release.Version}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	parallelism := um.Parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+	sem := make(chan struct{}, parallelism)
 
 	comparisons := make([]*ReleaseComparer, len(um.Helmfile.Releases))
+	errs := make([]error, len(keys))
 
 	var wg sync.WaitGroup
-	wg.Add(len(um.Helmfile.Releases))
-	for i, release := range um.Helmfile.Releases {
-		go func(i int, release Release) {
+	wg.Add(len(keys))
+	for i, key := range keys {
+		go func(i int, key chartKey) {
 			defer wg.Done()
 
-			var comparer *ReleaseComparer
-			comparer, err = um.GetReleaseComparer(release)
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			indices := groups[key]
+			representative := um.Helmfile.Releases[indices[0]]
+
+			comparer, err := um.GetReleaseComparer(representative)
 			if err != nil {
-				err = errors.Join(err, fmt.Errorf("failed to get release comparer for release %v: %v", release.Name, err))
+				errs[i] = fmt.Errorf("failed to get release comparer for chart %v: %w", key.Chart, err)
+				return
 			}
 
-			comparisons[i] = comparer
-		}(i, release)
+			for _, idx := range indices {
+				comparisons[idx] = &ReleaseComparer{
+					Current:         um.Helmfile.Releases[idx],
+					Latest:          comparer.Latest,
+					LatestAvailable: comparer.LatestAvailable,
+					Constrained:     comparer.Constrained,
+					Blocked:         comparer.Blocked,
+				}
+			}
+		}(i, key)
 	}
 	wg.Wait()
 
 	um.Comparisons = comparisons
 
-	return err
+	return errors.Join(errs...)
 }
 
 func getColumnPaddings(comparisons []*ReleaseComparer) (int, int) {
@@ -201,8 +326,10 @@ func getColumnPaddings(comparisons []*ReleaseComparer) (int, int) {
 		if len(release.Name()) > namePadding {
 			namePadding = len(release.Name())
 		}
-		if len(release.Current.Version) > versionPadding {
-			versionPadding = len(release.Current.Version)
+		for _, version := range []string{release.Current.Version, release.Latest.Version, release.LatestAvailable.Version} {
+			if len(version) > versionPadding {
+				versionPadding = len(version)
+			}
 		}
 	}
 	namePadding = namePadding + 1
@@ -211,18 +338,42 @@ func getColumnPaddings(comparisons []*ReleaseComparer) (int, int) {
 }
 
 func main() {
-	flagPath := flag.String("path", "helmfile.yaml", "Path to helmfile.yaml.")
-	flagStatus := flag.String("status", "all", "Filter releases by status. Valid values [all|latest|outdated].")
-	flagUpdateRepos := flag.Bool("update-repos", false, "Whether or not to update helm repos.")
+	if len(os.Args) > 1 && os.Args[1] == "bump" {
+		if err := runBump(os.Args[2:]); err != nil {
+			log.Fatalf("Failed to bump: %v", err)
+		}
+		return
+	}
+
+	runList(os.Args[1:])
+}
+
+func runList(args []string) {
+	fs := flag.NewFlagSet("helmfile-next-version", flag.ExitOnError)
+
+	flagPath := fs.String("path", "helmfile.yaml", "Path to helmfile.yaml.")
+	flagStatus := fs.String("status", "all", "Filter releases by status. Valid values [all|latest|outdated|major|minor|patch|blocked].")
+	flagUpdateRepos := fs.Bool("update-repos", false, "Whether or not to update helm repos.")
+	flagIncludePrerelease := fs.Bool("include-prerelease", false, "Whether or not to consider pre-release versions when resolving OCI chart tags.")
+	flagEnvironment := fs.String("environment", "default", "Name of the helmfile environment whose values to render.")
+	flagParallelism := fs.Int("parallelism", runtime.NumCPU(), "Maximum number of concurrent helm invocations.")
 
-	flag.Parse()
+	fs.Parse(args)
 
-	helmfile, err := NewHelmfile(*flagPath)
+	helmfile, err := NewHelmfile(*flagPath, *flagEnvironment)
 	if err != nil {
 		log.Fatalf("Failed to load helmfile: %v", err)
 	}
 
+	he, err := helmexec.New()
+	if err != nil {
+		log.Fatalf("Failed to detect helm version: %v", err)
+	}
+
 	updateManager := NewUpdateManager(helmfile)
+	updateManager.IncludePrerelease = *flagIncludePrerelease
+	updateManager.Parallelism = *flagParallelism
+	updateManager.HelmExec = he
 
 	if *flagUpdateRepos {
 		if err = updateManager.UpdateRepositories(); err != nil {
@@ -245,28 +396,34 @@ func main() {
 
 	fmt.Println()
 	fmt.Printf(
-		"%-[1]*[2]s %-[3]*[4]s  %[3]*[5]s %[3]*[6]s\n",
+		"%-[1]*[2]s %-[3]*[4]s  %-[3]*[5]s %-[3]*[6]s %[3]*[7]s\n",
 		namePadding,
 		"Chart",
 		versionPadding,
 		"Current",
-		"Latest",
+		"Latest (allowed)",
+		"Latest (available)",
 		"Status",
 	)
 
 	for _, release := range updateManager.Comparisons {
 		status := "‚úÖ"
-		if release.HasUpdate() {
+		latest := release.Latest.Version
+		if release.Blocked {
+			status = "üö´"
+			latest = "blocked"
+		} else if release.HasUpdate() {
 			status = "‚¨ÜÔ∏è"
 		}
 
 		text := fmt.Sprintf(
-			"%-[1]*[2]s %-[3]*[4]s  %[3]*[5]s     %[6]s",
+			"%-[1]*[2]s %-[3]*[4]s  %-[3]*[5]s %-[3]*[6]s %[7]s",
 			namePadding,
 			release.Name(),
 			versionPadding,
 			release.Current.Version,
-			release.Latest.Version,
+			latest,
+			release.LatestAvailable.Version,
 			status,
 		)
 
@@ -281,6 +438,14 @@ func main() {
 			if !release.HasUpdate() {
 				fmt.Println(text)
 			}
+		case "blocked":
+			if release.Blocked {
+				fmt.Println(text)
+			}
+		case "major", "minor", "patch":
+			if release.UpdateSeverity() == *flagStatus {
+				fmt.Println(text)
+			}
 		}
 	}
 }
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestUpdateSeverity(t *testing.T) {
+	tests := []struct {
+		name string
+		rc   ReleaseComparer
+		want string
+	}{
+		{
+			name: "patch bump",
+			rc:   ReleaseComparer{Current: Release{Version: "1.2.3"}, Latest: Release{Version: "1.2.4"}},
+			want: "patch",
+		},
+		{
+			name: "minor bump",
+			rc:   ReleaseComparer{Current: Release{Version: "1.2.3"}, Latest: Release{Version: "1.3.0"}},
+			want: "minor",
+		},
+		{
+			name: "major bump",
+			rc:   ReleaseComparer{Current: Release{Version: "1.2.3"}, Latest: Release{Version: "2.0.0"}},
+			want: "major",
+		},
+		{
+			name: "already latest",
+			rc:   ReleaseComparer{Current: Release{Version: "1.2.3"}, Latest: Release{Version: "1.2.3"}},
+			want: "",
+		},
+		{
+			name: "constrained compares Latest against LatestAvailable",
+			rc: ReleaseComparer{
+				Constrained:     true,
+				Current:         Release{Version: "^1.0.0"},
+				Latest:          Release{Version: "1.4.0"},
+				LatestAvailable: Release{Version: "2.0.0"},
+			},
+			want: "major",
+		},
+		{
+			name: "blocked constraint reports blocked regardless of versions",
+			rc: ReleaseComparer{
+				Constrained: true,
+				Blocked:     true,
+				Current:     Release{Version: "^1.0.0"},
+			},
+			want: "blocked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rc.UpdateSeverity(); got != tt.want {
+				t.Errorf("UpdateSeverity() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseComparerHasUpdateBlocked(t *testing.T) {
+	rc := ReleaseComparer{Constrained: true, Blocked: true}
+	if !rc.HasUpdate() {
+		t.Error("HasUpdate() = false for a blocked release, want true")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+)
+
+const ociScheme = "oci://"
+
+func isOCIChart(release Release) bool {
+	return release.OCI || strings.HasPrefix(release.Chart, ociScheme)
+}
+
+// getOCIReleaseComparer resolves the latest version of an OCI-hosted chart by
+// listing the image's tags in the registry, since `helm search repo` has no
+// notion of OCI registries.
+func (um *UpdateManager) getOCIReleaseComparer(release Release) (*ReleaseComparer, error) {
+	image := strings.TrimPrefix(release.Chart, ociScheme)
+
+	tags, err := crane.ListTags(image, crane.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags for %s: %w", image, err)
+	}
+
+	versions := make(semver.Collection, 0, len(tags))
+	for _, tag := range tags {
+		v, err := semver.NewVersion(tag)
+		if err != nil {
+			continue
+		}
+		if v.Prerelease() != "" && !um.IncludePrerelease {
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no semver tags found for chart %s", release.Chart)
+	}
+
+	sort.Sort(versions)
+	latest := Release{
+		Name:    release.Name,
+		Chart:   release.Chart,
+		Version: versions[len(versions)-1].Original(),
+	}
+
+	return &ReleaseComparer{
+		Current:         release,
+		Latest:          latest,
+		LatestAvailable: latest,
+	}, nil
+}